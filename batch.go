@@ -0,0 +1,281 @@
+//
+// Android Package Puller
+//
+// Non-interactive batch mode for CI and scripting: match packages by
+// glob, pull them all without prompting, and emit a machine-readable
+// summary.
+//
+
+package main // github.com/slightfoot/android-package-puller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config bundles the flags that select and shape a run, so the batch
+// flow can be driven directly in tests. The -info, -zip and -bundle
+// flags are not part of it and are still read from package-level state.
+type Config struct {
+	Match  []string
+	Out    string
+	All    bool
+	System bool
+	DryRun bool
+	JSON   bool
+}
+
+// PullResult is the machine-readable outcome of pulling a single
+// package, as emitted by -json.
+type PullResult struct {
+	Package     string `json:"package"`
+	Path        string `json:"path,omitempty"`
+	VersionCode string `json:"versionCode,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+	Bytes       int64  `json:"bytes,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// globList collects repeated -match flag occurrences.
+type globList []string
+
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globList) Set(value string) error {
+	*g = append(*g, value)
+	return nil
+}
+
+var (
+	matchPatterns globList
+	outDir        string
+	allPackages   bool
+	includeSystem bool
+	dryRun        bool
+	jsonOutput    bool
+)
+
+func init() {
+	flag.Var(&matchPatterns, "match",
+		"Shell glob matched against package names (repeatable). Enables batch mode.")
+
+	flag.StringVar(&outDir, "out", "",
+		"Destination directory for pulled packages, created if missing.")
+
+	flag.BoolVar(&allPackages, "all", false,
+		"Pull every package on the device. Enables batch mode.")
+
+	flag.BoolVar(&includeSystem, "system", false,
+		"Include /system/ packages, which are skipped by default (pass -system=true to include them).")
+
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"List what would be pulled without pulling (batch mode only).")
+
+	flag.BoolVar(&jsonOutput, "json", false,
+		"Print a JSON summary of the pull results (batch mode only).")
+}
+
+func buildConfig() Config {
+	return Config{
+		Match:  []string(matchPatterns),
+		Out:    outDir,
+		All:    allPackages,
+		System: includeSystem,
+		DryRun: dryRun,
+		JSON:   jsonOutput,
+	}
+}
+
+// runBatch matches pkgs against cfg, then pulls (or lists, for -dry-run)
+// every match and prints a summary. It takes a DeviceAPI rather than a
+// concrete *adb.Device so it can be exercised with a fake device.
+func runBatch(ctx context.Context, device DeviceAPI, pkgs []*Package, cfg Config) error {
+	matched := matchPackages(pkgs, cfg)
+	if len(matched) == 0 {
+		return printError("No packages matched -match/-all criteria")
+	}
+
+	// -info: print what we know about each match and stop, same contract
+	// as the interactive flow - never pull.
+	if infoOnly {
+		return printBatchInfo(device, matched, cfg.JSON)
+	}
+
+	if len(cfg.Out) > 0 {
+		if err := os.MkdirAll(cfg.Out, 0755); err != nil {
+			return printError("Failed to create -out directory: %s", err.Error())
+		}
+	}
+
+	if cfg.DryRun {
+		if cfg.JSON {
+			results := make([]PullResult, len(matched))
+			for i, pkg := range matched {
+				results[i] = PullResult{Package: pkg.Name, Path: pkg.Path}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(results); err != nil {
+				return printError("Failed to encode results: %s", err.Error())
+			}
+			return nil
+		}
+		for _, pkg := range matched {
+			fmt.Printf("%s\t%s\n", pkg.Name, pkg.Path)
+		}
+		return nil
+	}
+
+	results := make([]PullResult, 0, len(matched))
+	failed := 0
+	for _, pkg := range matched {
+		result := pullOne(device, pkg, cfg.Out)
+		if len(result.Error) > 0 {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if cfg.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return printError("Failed to encode results: %s", err.Error())
+		}
+	} else {
+		for _, result := range results {
+			if len(result.Error) > 0 {
+				fmt.Printf("%s\tFAILED: %s\n", result.Package, result.Error)
+			} else {
+				fmt.Printf("%s\t%s\n", result.Package, result.Path)
+			}
+		}
+		fmt.Printf("Done: %d succeeded, %d failed\n", len(results)-failed, failed)
+	}
+
+	if failed > 0 {
+		return printError("%d package(s) failed to pull", failed)
+	}
+
+	return nil
+}
+
+// printBatchInfo prints versionCode/versionName for each of matched
+// without pulling anything, honouring -info in batch mode.
+func printBatchInfo(device DeviceAPI, matched []*Package, asJSON bool) error {
+	type infoResult struct {
+		Package     string `json:"package"`
+		VersionCode string `json:"versionCode,omitempty"`
+		VersionName string `json:"versionName,omitempty"`
+	}
+
+	results := make([]infoResult, 0, len(matched))
+	for _, pkg := range matched {
+		result := infoResult{Package: pkg.Name}
+		if info, err := getVersionInfo(device, pkg.Name); err == nil {
+			result.VersionCode, result.VersionName = info.Code, info.Name
+		}
+		results = append(results, result)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return printError("Failed to encode results: %s", err.Error())
+		}
+		return nil
+	}
+
+	for _, result := range results {
+		fmt.Printf("%s\tversionCode=%s\tversionName=%s\n", result.Package, result.VersionCode, result.VersionName)
+	}
+	return nil
+}
+
+// matchPackages applies cfg's -all/-match/-system filters to pkgs.
+func matchPackages(pkgs []*Package, cfg Config) []*Package {
+	matched := make([]*Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		if !cfg.System && isSystemPackage(pkg) {
+			continue
+		}
+		if len(cfg.Match) > 0 && !matchesAny(pkg.Name, cfg.Match) {
+			continue
+		}
+		matched = append(matched, pkg)
+	}
+	return matched
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isSystemPackage(pkg *Package) bool {
+	return strings.HasPrefix(pkg.Path, "/system/")
+}
+
+// pullOne pulls a single package and reports its outcome as a PullResult.
+func pullOne(device DeviceAPI, pkg *Package, outDir string) PullResult {
+	result := PullResult{Package: pkg.Name}
+
+	if info, err := getVersionInfo(device, pkg.Name); err == nil {
+		pkg.VersionCode, pkg.VersionName = info.Code, info.Name
+		result.VersionCode = info.Code
+	}
+
+	path, err := pullPackage(device, pkg, outDir)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Path = path
+
+	if sum, size, err := hashFile(path); err == nil {
+		result.SHA256, result.Bytes = sum, size
+	}
+
+	return result
+}
+
+// hashFile returns the sha256 and size of the file at path. It returns
+// a zero value, rather than an error, for directories (pulled splits
+// that weren't bundled into a single archive have no single hash).
+func hashFile(path string) (string, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	if info.IsDir() {
+		return "", 0, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), info.Size(), nil
+}
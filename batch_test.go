@@ -0,0 +1,137 @@
+package main // github.com/slightfoot/android-package-puller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeCommander is a canned Commander result for tests.
+type fakeCommander struct {
+	output string
+}
+
+func (f fakeCommander) Call() (string, error) {
+	return f.output, nil
+}
+
+// fakeDevice is a DeviceAPI that never touches real hardware: `pm path`
+// reports a single base apk, `dumpsys package` reports a fixed version,
+// and Pull just writes canned bytes to the requested local path.
+type fakeDevice struct{}
+
+func (f *fakeDevice) Command(name string, args ...string) Commander {
+	switch name {
+	case "pm":
+		return fakeCommander{output: "package:/data/app/~~x/base.apk\n"}
+	case "dumpsys":
+		return fakeCommander{output: "    versionCode=42 minSdk=21\n    versionName=1.0\n"}
+	default:
+		return fakeCommander{}
+	}
+}
+
+func (f *fakeDevice) Pull(remote string, local string) error {
+	return os.WriteFile(local, []byte("fake-apk-contents"), 0644)
+}
+
+func TestMatchPackages(t *testing.T) {
+	pkgs := []*Package{
+		{Name: "com.example.app", Path: "/data/app/com.example.app-1/base.apk"},
+		{Name: "com.example.debug", Path: "/data/app/com.example.debug-1/base.apk"},
+		{Name: "com.android.settings", Path: "/system/app/Settings/Settings.apk"},
+	}
+
+	cases := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{
+			name: "all, system excluded by default",
+			cfg:  Config{All: true},
+			want: []string{"com.example.app", "com.example.debug"},
+		},
+		{
+			name: "all, system included",
+			cfg:  Config{All: true, System: true},
+			want: []string{"com.example.app", "com.example.debug", "com.android.settings"},
+		},
+		{
+			name: "match glob",
+			cfg:  Config{Match: []string{"com.example.*"}},
+			want: []string{"com.example.app", "com.example.debug"},
+		},
+		{
+			name: "match glob honors system filter",
+			cfg:  Config{Match: []string{"com.*"}},
+			want: []string{"com.example.app", "com.example.debug"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			matched := matchPackages(pkgs, c.cfg)
+			if len(matched) != len(c.want) {
+				t.Fatalf("matchPackages() = %v, want %v", matched, c.want)
+			}
+			for i, pkg := range matched {
+				if pkg.Name != c.want[i] {
+					t.Fatalf("matchPackages()[%d] = %q, want %q", i, pkg.Name, c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunBatchPullsMatchedPackages(t *testing.T) {
+	pkgs := []*Package{
+		{Name: "com.example.app", Path: "/data/app/com.example.app-1/base.apk"},
+	}
+	outDir := t.TempDir()
+
+	err := runBatch(context.Background(), &fakeDevice{}, pkgs, Config{All: true, Out: outDir})
+	if err != nil {
+		t.Fatalf("runBatch() error = %v", err)
+	}
+
+	apkPath := filepath.Join(outDir, "com.example.app-42.apk")
+	if _, err := os.Stat(apkPath); err != nil {
+		t.Fatalf("expected pulled apk at %s: %v", apkPath, err)
+	}
+}
+
+func TestRunBatchNoMatchesIsError(t *testing.T) {
+	pkgs := []*Package{
+		{Name: "com.android.settings", Path: "/system/app/Settings/Settings.apk"},
+	}
+
+	err := runBatch(context.Background(), &fakeDevice{}, pkgs, Config{All: true})
+	if err == nil {
+		t.Fatal("runBatch() error = nil, want error for zero matches")
+	}
+}
+
+func TestRunBatchInfoOnlyDoesNotPull(t *testing.T) {
+	pkgs := []*Package{
+		{Name: "com.example.app", Path: "/data/app/com.example.app-1/base.apk"},
+	}
+	outDir := t.TempDir()
+
+	infoOnly = true
+	defer func() { infoOnly = false }()
+
+	err := runBatch(context.Background(), &fakeDevice{}, pkgs, Config{All: true, Out: outDir})
+	if err != nil {
+		t.Fatalf("runBatch() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("ReadDir(%s) error = %v", outDir, err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("-info pulled files into %s: %v", outDir, entries)
+	}
+}
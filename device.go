@@ -0,0 +1,101 @@
+//
+// Android Package Puller
+//
+// Auto-starting the adb server and reporting richer device metadata,
+// borrowed from fdroidcl's startAdbIfNeeded.
+//
+
+package main // github.com/slightfoot/android-package-puller
+
+import (
+	"fmt"
+	"strings"
+
+	"android.googlesource.com/platform/tools/gpu/adb"
+)
+
+// deviceInfo holds metadata about a device that isn't part of adb.Device
+// itself, fetched lazily via getprop so it only costs a round trip for
+// devices we actually need to disambiguate.
+type deviceInfo struct {
+	Model   string
+	Product string
+	Release string
+}
+
+type (
+	// Commander is the subset of *adb.Command we depend on.
+	Commander interface {
+		Call() (string, error)
+	}
+
+	// DeviceAPI is the subset of *adb.Device we depend on, extracted so
+	// the batch pull flow can be driven in tests by a fake device.
+	DeviceAPI interface {
+		Command(name string, args ...string) Commander
+		Pull(remote string, local string) error
+	}
+)
+
+// realDevice adapts an *adb.Device to DeviceAPI.
+type realDevice struct {
+	dev *adb.Device
+}
+
+func wrapDevice(dev *adb.Device) DeviceAPI {
+	return &realDevice{dev: dev}
+}
+
+func (r *realDevice) Command(name string, args ...string) Commander {
+	return r.dev.Command(name, args...)
+}
+
+func (r *realDevice) Pull(remote string, local string) error {
+	return r.dev.Pull(remote, local)
+}
+
+// startAdbIfNeeded launches the adb server if it isn't already running,
+// so users don't hit an opaque failure on first run.
+func startAdbIfNeeded() error {
+	running, err := adb.IsServerRunning()
+	if err != nil {
+		return printError("Failed to check adb server status: %s", err.Error())
+	}
+	if running {
+		return nil
+	}
+
+	fmt.Printf("Starting adb server...\n")
+	if err := adb.StartServer(); err != nil {
+		return printError("Failed to start adb server: %s", err.Error())
+	}
+
+	return nil
+}
+
+// getDeviceInfo fetches model, product and Android version for device.
+func getDeviceInfo(device DeviceAPI) (*deviceInfo, error) {
+	model, err := getProp(device, "ro.product.model")
+	if err != nil {
+		return nil, err
+	}
+	product, err := getProp(device, "ro.product.name")
+	if err != nil {
+		return nil, err
+	}
+	release, err := getProp(device, "ro.build.version.release")
+	if err != nil {
+		return nil, err
+	}
+
+	return &deviceInfo{Model: model, Product: product, Release: release}, nil
+}
+
+func getProp(device DeviceAPI, prop string) (string, error) {
+	cmd := device.Command("getprop", prop)
+	data, err := cmd.Call()
+	if err != nil {
+		return "", printError("Failed to read %s: %s", prop, err.Error())
+	}
+	return strings.TrimSpace(data), nil
+}
@@ -12,6 +12,7 @@ package main // github.com/slightfoot/android-package-puller
 import (
 	"android.googlesource.com/platform/tools/gpu/adb"
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -21,8 +22,10 @@ import (
 
 type (
 	Package struct {
-		Name string
-		Path string
+		Name        string
+		Path        string
+		VersionCode string
+		VersionName string
 	}
 )
 
@@ -60,42 +63,98 @@ func main() {
 	// Handle command line flags
 	flag.Parse()
 
+	if err := run(context.Background(), buildConfig()); err != nil {
+		os.Exit(1)
+	}
+}
+
+// run drives the whole tool: it picks a device, lists packages and then
+// either pulls the interactively-chosen ones or, when cfg selects batch
+// mode (-all / -match), hands off to runBatch.
+func run(ctx context.Context, cfg Config) error {
+	// Make sure the adb server is up before we ask it anything
+	if err := startAdbIfNeeded(); err != nil {
+		return err
+	}
+
 	// Get list of devices
 	devices, err := adb.Devices()
 	if err != nil {
-		printError("Failed to get list of devices: %s\n", err.Error())
-		os.Exit(1)
+		return printError("Failed to get list of devices: %s", err.Error())
 	}
 
 	// Choose device if multiple devices attached to machine
 	device, err := getDevice(devices)
 	if err != nil {
-		os.Exit(1)
+		return err
 	}
+	dev := wrapDevice(device)
 
 	// Get list of packages from device
-	pkgs, err := getPackageList(device)
+	pkgs, err := getPackageList(dev)
 	if err != nil {
-		os.Exit(1)
+		return err
+	}
+
+	if cfg.All || len(cfg.Match) > 0 {
+		return runBatch(ctx, dev, pkgs, cfg)
 	}
 
-	// Choose package
-	pkg, err := getPackage(pkgs)
+	// Choose one or more packages
+	chosen, err := getPackages(dev, pkgs)
 	if err != nil {
-		os.Exit(1)
+		return err
 	}
 
-	// Pull package from device
-	apkName := pkg.Name + ".apk"
-	fmt.Printf("Pulling %s from device... ", apkName)
-	err = device.Pull(pkg.Path, apkName)
-	if err == nil {
-		fmt.Printf("Success\n")
-	} else {
-		fmt.Printf("Failed\n")
-		printError("Failed to pull package from device: %s", err.Error())
-		os.Exit(1)
+	// -info: just print what we know and stop, no pulling
+	if infoOnly {
+		for _, pkg := range chosen {
+			fmt.Printf("%s\tversionCode=%s\tversionName=%s\n", pkg.Name, pkg.VersionCode, pkg.VersionName)
+		}
+		return nil
 	}
+
+	if len(cfg.Out) > 0 {
+		if err := os.MkdirAll(cfg.Out, 0755); err != nil {
+			return printError("Failed to create -out directory: %s", err.Error())
+		}
+	}
+
+	// Pull each chosen package, tallying up how many succeeded
+	succeeded, failed := 0, 0
+	for _, pkg := range chosen {
+		if _, err := pullPackage(dev, pkg, cfg.Out); err != nil {
+			failed++
+			continue
+		}
+		succeeded++
+	}
+
+	if len(chosen) > 1 {
+		fmt.Printf("Done: %d succeeded, %d failed\n", succeeded, failed)
+	}
+	if failed > 0 {
+		return printError("%d package(s) failed to pull", failed)
+	}
+	return nil
+}
+
+// pullPackage works out which apk(s) make up pkg and pulls them from
+// device into outDir (the current directory when empty), returning the
+// path of the resulting artifact.
+func pullPackage(device DeviceAPI, pkg *Package, outDir string) (string, error) {
+	splits, err := getSplits(device, pkg)
+	if err != nil {
+		return "", err
+	}
+
+	apkName := pkg.Name
+	if len(pkg.VersionCode) > 0 {
+		apkName += "-" + pkg.VersionCode
+	}
+	apkName += ".apk"
+
+	return pullSplits(device, pkg, splits, apkName, outDir)
 }
 
 func getDevice(devices []*adb.Device) (*adb.Device, error) {
@@ -118,7 +177,12 @@ func getDevice(devices []*adb.Device) (*adb.Device, error) {
 	// Otherwise we show a list of devices to the user
 	fmt.Printf("Devices:\n")
 	for i, device := range devices {
-		fmt.Printf("\t%d:\t%s %s\n", i, device.Serial, device.State)
+		info, err := getDeviceInfo(wrapDevice(device))
+		if err != nil {
+			fmt.Printf("\t%d:\t%s  [%s]\n", i, device.Serial, device.State)
+			continue
+		}
+		fmt.Printf("\t%d:\t%s  %s (Android %s) [%s]\n", i, device.Serial, info.Model, info.Release, device.State)
 	}
 	index, err := readInputNumber("Which device?", 0, len(devices)-1)
 	if err != nil {
@@ -128,7 +192,7 @@ func getDevice(devices []*adb.Device) (*adb.Device, error) {
 	return devices[index], nil
 }
 
-func getPackageList(device *adb.Device) ([]*Package, error) {
+func getPackageList(device DeviceAPI) ([]*Package, error) {
 	cmd := device.Command("pm", "list", "packages", "-f")
 	data, err := cmd.Call()
 	if err != nil {
@@ -159,27 +223,72 @@ func getPackageList(device *adb.Device) ([]*Package, error) {
 	return packages, nil
 }
 
-func getPackage(pkgs []*Package) (*Package, error) {
-	// Did the user ask for a specific package? if located, return it
+func getPackages(device DeviceAPI, pkgs []*Package) ([]*Package, error) {
+	// Did the user ask for a specific package? if located, return it. The
+	// appid:versionCode form (e.g. com.example.app:12345) pins the pull to
+	// a specific installed version.
 	if len(packageName) > 0 {
+		name, wantVersionCode := splitAppID(packageName)
+
 		for _, pkg := range pkgs {
-			if pkg.Name == packageName {
-				return pkg, nil
+			if pkg.Name != name {
+				continue
+			}
+
+			info, err := getVersionInfo(device, pkg.Name)
+			if err == nil {
+				pkg.VersionCode, pkg.VersionName = info.Code, info.Name
+			}
+
+			if len(wantVersionCode) > 0 && pkg.VersionCode != wantVersionCode {
+				return nil, printError("Device has %s version %s, requested %s",
+					pkg.Name, pkg.VersionCode, wantVersionCode)
 			}
+
+			return []*Package{pkg}, nil
 		}
-		return nil, printError("Could not locate package: %s", packageName)
+		return nil, printError("Could not locate package: %s", name)
+	}
+
+	pkgs, err := filterPackages(pkgs, searchPattern)
+	if err != nil {
+		return nil, err
 	}
 
+	// Only the -search narrowed list is small enough to afford a dumpsys
+	// round-trip per entry; an unfiltered device listing can run into the
+	// hundreds, so version info for those is fetched lazily below, only
+	// for whichever package(s) the user actually picks.
+	showVersions := len(searchPattern) > 0
+
 	fmt.Printf("Packages:\n")
 	for i, pkg := range pkgs {
-		fmt.Printf("\t%d:\t%s %s\n", i, pkg.Name, pkg.Path)
+		if showVersions {
+			if info, err := getVersionInfo(device, pkg.Name); err == nil {
+				pkg.VersionCode, pkg.VersionName = info.Code, info.Name
+			}
+			fmt.Printf("\t%d:\t%s  %s (%s)  %s\n", i, pkg.Name, pkg.VersionName, pkg.VersionCode, pkg.Path)
+		} else {
+			fmt.Printf("\t%d:\t%s %s\n", i, pkg.Name, pkg.Path)
+		}
 	}
-	index, err := readInputNumber("Which package?", 0, len(pkgs)-1)
+	indices, err := readInputSelection("Which package(s)?", len(pkgs)-1)
 	if err != nil {
 		return nil, err
 	}
 
-	return pkgs[index], nil
+	chosen := make([]*Package, len(indices))
+	for i, index := range indices {
+		pkg := pkgs[index]
+		if !showVersions {
+			if info, err := getVersionInfo(device, pkg.Name); err == nil {
+				pkg.VersionCode, pkg.VersionName = info.Code, info.Name
+			}
+		}
+		chosen[i] = pkg
+	}
+
+	return chosen, nil
 }
 
 func readInputNumber(prompt string, min int, max int) (int, error) {
@@ -0,0 +1,170 @@
+//
+// Android Package Puller
+//
+// Regex filtering and multi-select range syntax for package selection,
+// modelled on fdroidcl's "search" and yay's numbered menu.
+//
+
+package main // github.com/slightfoot/android-package-puller
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var searchPattern string
+
+func init() {
+	flag.StringVar(&searchPattern, "search", "",
+		"Regexp filtering the package list by name before selection.")
+}
+
+// filterPackages drops any package whose name does not match pattern. An
+// empty pattern is a no-op.
+func filterPackages(pkgs []*Package, pattern string) ([]*Package, error) {
+	if len(pattern) == 0 {
+		return pkgs, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, printError("Invalid -search pattern: %s", err.Error())
+	}
+
+	filtered := make([]*Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		if re.MatchString(pkg.Name) {
+			filtered = append(filtered, pkg)
+		}
+	}
+
+	if len(filtered) == 0 {
+		return nil, printError("No packages match -search %q", pattern)
+	}
+
+	return filtered, nil
+}
+
+// readInputSelection reads a compound selection expression matching the
+// grammar:
+//
+//	SEL   := TOKEN ("," TOKEN)*
+//	TOKEN := N | N-M | ^N | ^N-M
+//
+// Plain tokens (N or N-M) are inclusive picks; ^ tokens exclude from the
+// current set. Bare ^ tokens with no positive tokens anywhere in the
+// expression mean "all except these". The result is a sorted,
+// deduplicated slice of 0-based indices in [0, max].
+func readInputSelection(prompt string, max int) ([]int, error) {
+	fmt.Printf("%s [0-%d]: ", prompt, max)
+
+	reader := bufio.NewReader(os.Stdin)
+	input, err := reader.ReadString('\n')
+	if err == nil {
+		input = strings.TrimSpace(input)
+	}
+	if err != nil || len(input) == 0 {
+		return nil, printError("Input cancelled")
+	}
+
+	return parseSelection(input, max)
+}
+
+func parseSelection(input string, max int) ([]int, error) {
+	var includes, excludes []int
+	haveIncludes := false
+
+	for _, tok := range strings.Split(input, ",") {
+		tok = strings.TrimSpace(tok)
+		if len(tok) == 0 {
+			continue
+		}
+
+		exclude := strings.HasPrefix(tok, "^")
+		if exclude {
+			tok = tok[1:]
+		}
+
+		lo, hi, err := parseRange(tok, max)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := lo; i <= hi; i++ {
+			if exclude {
+				excludes = append(excludes, i)
+			} else {
+				includes = append(includes, i)
+				haveIncludes = true
+			}
+		}
+	}
+
+	var picked []int
+	if haveIncludes {
+		picked = includes
+	} else {
+		picked = make([]int, max+1)
+		for i := range picked {
+			picked[i] = i
+		}
+	}
+
+	excluded := make(map[int]bool, len(excludes))
+	for _, i := range excludes {
+		excluded[i] = true
+	}
+
+	set := make(map[int]bool, len(picked))
+	for _, i := range picked {
+		if !excluded[i] {
+			set[i] = true
+		}
+	}
+
+	if len(set) == 0 {
+		return nil, printError("Selection is empty")
+	}
+
+	result := make([]int, 0, len(set))
+	for i := range set {
+		result = append(result, i)
+	}
+	sort.Ints(result)
+
+	return result, nil
+}
+
+// parseRange parses a single "N" or "N-M" token (the leading "^" is
+// expected to already have been stripped) and validates it against max.
+func parseRange(tok string, max int) (int, int, error) {
+	parts := strings.SplitN(tok, "-", 2)
+
+	lo, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, printError("Invalid selection token: %s", tok)
+	}
+
+	hi := lo
+	if len(parts) == 2 {
+		hi, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, 0, printError("Invalid selection token: %s", tok)
+		}
+	}
+
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	if lo < 0 || hi > max {
+		return 0, 0, printError("Selection out of range: %s", tok)
+	}
+
+	return lo, hi, nil
+}
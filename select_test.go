@@ -0,0 +1,49 @@
+package main // github.com/slightfoot/android-package-puller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		max     int
+		want    []int
+		wantErr bool
+	}{
+		{name: "single", input: "2", max: 4, want: []int{2}},
+		{name: "range", input: "1-3", max: 4, want: []int{1, 2, 3}},
+		{name: "reversed range", input: "3-1", max: 4, want: []int{1, 2, 3}},
+		{name: "comma list", input: "0,2,4", max: 4, want: []int{0, 2, 4}},
+		{name: "duplicates collapse", input: "1,1,1-2", max: 4, want: []int{1, 2}},
+		{name: "exclude from range", input: "0-4,^2", max: 4, want: []int{0, 1, 3, 4}},
+		{name: "exclude range", input: "0-4,^1-2", max: 4, want: []int{0, 3, 4}},
+		{name: "bare excludes mean all except", input: "^1,^3", max: 4, want: []int{0, 2, 4}},
+		{name: "bare exclude range means all except", input: "^1-2", max: 4, want: []int{0, 3, 4}},
+		{name: "conflicting include/exclude is empty", input: "1,^1", max: 4, wantErr: true},
+		{name: "out of range", input: "5", max: 4, wantErr: true},
+		{name: "negative out of range", input: "-1", max: 4, wantErr: true},
+		{name: "garbage token", input: "abc", max: 4, wantErr: true},
+		{name: "empty input selects everything", input: "", max: 4, want: []int{0, 1, 2, 3, 4}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseSelection(c.input, c.max)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseSelection(%q) = %v, want error", c.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSelection(%q) unexpected error: %v", c.input, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("parseSelection(%q) = %v, want %v", c.input, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+//
+// Android Package Puller
+//
+// Support for pulling split APKs (app bundles installed as base + config
+// splits) as a single operation.
+//
+
+package main // github.com/slightfoot/android-package-puller
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// Split represents a single APK belonging to a package, either the
+	// base APK or one of its config/feature splits.
+	Split struct {
+		Name string // destination file name, e.g. base.apk
+		Path string // path to the apk on the device
+	}
+
+	apksManifest struct {
+		Package string   `json:"package"`
+		Splits  []string `json:"splits"`
+	}
+)
+
+var (
+	zipSplits   bool
+	bundleSplit bool
+)
+
+func init() {
+	flag.BoolVar(&zipSplits, "zip", false,
+		"Also bundle pulled splits into an .apks archive. The split directory is kept beside it.")
+
+	flag.BoolVar(&bundleSplit, "bundle", false,
+		"Pull only the base APK, ignoring any config/feature splits. "+
+			"This does not merge splits into one APK, it just drops them.")
+}
+
+// getSplits asks the device for every APK that makes up pkg by running
+// `pm path` and parsing the `package:` lines it returns. Apps installed as
+// a single APK yield exactly one Split.
+func getSplits(device DeviceAPI, pkg *Package) ([]Split, error) {
+	cmd := device.Command("pm", "path", "--user", "0", pkg.Name)
+	data, err := cmd.Call()
+	if err != nil {
+		return nil, printError("Failed to retrieve apk paths: %s", err.Error())
+	}
+
+	lines := strings.Split(data, "\n")
+	splits := make([]Split, 0, len(lines))
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "package:") == false {
+			continue
+		}
+		path := line[len("package:"):]
+		splits = append(splits, Split{Name: filepath.Base(path), Path: path})
+	}
+
+	if len(splits) == 0 {
+		// Fall back to the path already known from the package listing.
+		splits = append(splits, Split{Name: pkg.Name + ".apk", Path: pkg.Path})
+	}
+
+	return splits, nil
+}
+
+// pullSplits pulls every split making up pkg into outDir (the current
+// directory when empty) and returns the path to the resulting artifact.
+// With a single split it preserves the original single-apk behaviour,
+// writing one file named apkName. With more than one split it writes
+// each apk into a directory named after the package and, if zipSplits
+// is set, also bundles them into an .apks archive next to that
+// directory (the directory is not removed). When bundleSplit is set
+// only the base APK is pulled.
+func pullSplits(device DeviceAPI, pkg *Package, splits []Split, apkName string, outDir string) (string, error) {
+	if bundleSplit {
+		splits = splits[:1]
+	}
+
+	if len(splits) == 1 {
+		dest := apkName
+		if len(outDir) > 0 {
+			dest = filepath.Join(outDir, apkName)
+		}
+		fmt.Printf("Pulling %s from device... ", dest)
+		err := device.Pull(splits[0].Path, dest)
+		if err != nil {
+			fmt.Printf("Failed\n")
+			return "", printError("Failed to pull package from device: %s", err.Error())
+		}
+		fmt.Printf("Success\n")
+		return dest, nil
+	}
+
+	dir := pkg.Name
+	if len(outDir) > 0 {
+		dir = filepath.Join(outDir, dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", printError("Failed to create output directory: %s", err.Error())
+	}
+
+	for _, split := range splits {
+		dest := filepath.Join(dir, split.Name)
+		fmt.Printf("Pulling %s from device... ", dest)
+		if err := device.Pull(split.Path, dest); err != nil {
+			fmt.Printf("Failed\n")
+			return "", printError("Failed to pull %s from device: %s", split.Name, err.Error())
+		}
+		fmt.Printf("Success\n")
+	}
+
+	if zipSplits {
+		return zipApks(pkg, dir, splits)
+	}
+
+	return dir, nil
+}
+
+// zipApks bundles the already-pulled splits in dir into a bundletool
+// compatible .apks archive: the raw apk files plus a manifest.json
+// describing the package and the split names it contains. It returns
+// the path to the archive it wrote.
+func zipApks(pkg *Package, dir string, splits []Split) (string, error) {
+	apksName := pkg.Name + ".apks"
+	if parent := filepath.Dir(dir); parent != "." {
+		apksName = filepath.Join(parent, apksName)
+	}
+	fmt.Printf("Writing %s... ", apksName)
+
+	out, err := os.Create(apksName)
+	if err != nil {
+		fmt.Printf("Failed\n")
+		return "", printError("Failed to create %s: %s", apksName, err.Error())
+	}
+	defer out.Close()
+
+	w := zip.NewWriter(out)
+	defer w.Close()
+
+	manifest := apksManifest{Package: pkg.Name}
+	for _, split := range splits {
+		manifest.Splits = append(manifest.Splits, split.Name)
+
+		entry, err := w.Create(split.Name)
+		if err != nil {
+			fmt.Printf("Failed\n")
+			return "", printError("Failed to add %s to archive: %s", split.Name, err.Error())
+		}
+		src, err := os.Open(filepath.Join(dir, split.Name))
+		if err != nil {
+			fmt.Printf("Failed\n")
+			return "", printError("Failed to read %s: %s", split.Name, err.Error())
+		}
+		_, err = io.Copy(entry, src)
+		src.Close()
+		if err != nil {
+			fmt.Printf("Failed\n")
+			return "", printError("Failed to write %s to archive: %s", split.Name, err.Error())
+		}
+	}
+
+	manifestEntry, err := w.Create("manifest.json")
+	if err != nil {
+		fmt.Printf("Failed\n")
+		return "", printError("Failed to add manifest to archive: %s", err.Error())
+	}
+	if err := json.NewEncoder(manifestEntry).Encode(manifest); err != nil {
+		fmt.Printf("Failed\n")
+		return "", printError("Failed to write manifest: %s", err.Error())
+	}
+
+	fmt.Printf("Success\n")
+	return apksName, nil
+}
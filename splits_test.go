@@ -0,0 +1,227 @@
+package main // github.com/slightfoot/android-package-puller
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeSplitDevice is a DeviceAPI whose `pm path` output is configurable
+// per test and which records which remote paths were pulled.
+type fakeSplitDevice struct {
+	pmOutput string
+	pulled   []string
+}
+
+func (f *fakeSplitDevice) Command(name string, args ...string) Commander {
+	if name == "pm" {
+		return fakeCommander{output: f.pmOutput}
+	}
+	return fakeCommander{}
+}
+
+func (f *fakeSplitDevice) Pull(remote string, local string) error {
+	f.pulled = append(f.pulled, remote)
+	return os.WriteFile(local, []byte("apk-bytes:"+filepath.Base(remote)), 0644)
+}
+
+func resetSplitFlags() {
+	zipSplits, bundleSplit = false, false
+}
+
+func TestGetSplits(t *testing.T) {
+	cases := []struct {
+		name     string
+		pmOutput string
+		pkgPath  string
+		want     []Split
+	}{
+		{
+			name:     "single apk, no splits reported",
+			pmOutput: "package:/data/app/~~x/base.apk\n",
+			want:     []Split{{Name: "base.apk", Path: "/data/app/~~x/base.apk"}},
+		},
+		{
+			name: "base plus two config splits",
+			pmOutput: "package:/data/app/~~x/base.apk\n" +
+				"package:/data/app/~~x/split_config.arm64_v8a.apk\n" +
+				"package:/data/app/~~x/split_config.en.apk\n",
+			want: []Split{
+				{Name: "base.apk", Path: "/data/app/~~x/base.apk"},
+				{Name: "split_config.arm64_v8a.apk", Path: "/data/app/~~x/split_config.arm64_v8a.apk"},
+				{Name: "split_config.en.apk", Path: "/data/app/~~x/split_config.en.apk"},
+			},
+		},
+		{
+			name:     "empty pm path falls back to the package listing's path",
+			pmOutput: "",
+			pkgPath:  "/data/app/com.example-1/base.apk",
+			want:     []Split{{Name: "com.example.apk", Path: "/data/app/com.example-1/base.apk"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			device := &fakeSplitDevice{pmOutput: c.pmOutput}
+			pkg := &Package{Name: "com.example", Path: c.pkgPath}
+
+			got, err := getSplits(device, pkg)
+			if err != nil {
+				t.Fatalf("getSplits() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("getSplits() = %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPullSplitsSingleWritesOneFile(t *testing.T) {
+	resetSplitFlags()
+	defer resetSplitFlags()
+
+	device := &fakeSplitDevice{}
+	pkg := &Package{Name: "com.example"}
+	splits := []Split{{Name: "base.apk", Path: "/device/base.apk"}}
+	outDir := t.TempDir()
+
+	path, err := pullSplits(device, pkg, splits, "com.example.apk", outDir)
+	if err != nil {
+		t.Fatalf("pullSplits() error = %v", err)
+	}
+
+	want := filepath.Join(outDir, "com.example.apk")
+	if path != want {
+		t.Fatalf("pullSplits() path = %q, want %q", path, want)
+	}
+	if entries, err := os.ReadDir(outDir); err != nil || len(entries) != 1 {
+		t.Fatalf("expected exactly one file in %s, got %v (err %v)", outDir, entries, err)
+	}
+}
+
+func TestPullSplitsMultipleWritesDirectory(t *testing.T) {
+	resetSplitFlags()
+	defer resetSplitFlags()
+
+	device := &fakeSplitDevice{}
+	pkg := &Package{Name: "com.example"}
+	splits := []Split{
+		{Name: "base.apk", Path: "/device/base.apk"},
+		{Name: "split_config.arm64_v8a.apk", Path: "/device/split_config.arm64_v8a.apk"},
+		{Name: "split_config.en.apk", Path: "/device/split_config.en.apk"},
+	}
+	outDir := t.TempDir()
+
+	path, err := pullSplits(device, pkg, splits, "com.example.apk", outDir)
+	if err != nil {
+		t.Fatalf("pullSplits() error = %v", err)
+	}
+
+	wantDir := filepath.Join(outDir, "com.example")
+	if path != wantDir {
+		t.Fatalf("pullSplits() path = %q, want %q", path, wantDir)
+	}
+	for _, split := range splits {
+		if _, err := os.Stat(filepath.Join(wantDir, split.Name)); err != nil {
+			t.Fatalf("missing pulled split %s: %v", split.Name, err)
+		}
+	}
+	if len(device.pulled) != len(splits) {
+		t.Fatalf("pulled %d splits, want %d", len(device.pulled), len(splits))
+	}
+}
+
+func TestPullSplitsBundleTruncatesToBase(t *testing.T) {
+	resetSplitFlags()
+	bundleSplit = true
+	defer resetSplitFlags()
+
+	device := &fakeSplitDevice{}
+	pkg := &Package{Name: "com.example"}
+	splits := []Split{
+		{Name: "base.apk", Path: "/device/base.apk"},
+		{Name: "split_config.arm64_v8a.apk", Path: "/device/split_config.arm64_v8a.apk"},
+	}
+	outDir := t.TempDir()
+
+	path, err := pullSplits(device, pkg, splits, "com.example.apk", outDir)
+	if err != nil {
+		t.Fatalf("pullSplits() error = %v", err)
+	}
+
+	want := filepath.Join(outDir, "com.example.apk")
+	if path != want {
+		t.Fatalf("-bundle path = %q, want %q", path, want)
+	}
+	if len(device.pulled) != 1 || device.pulled[0] != "/device/base.apk" {
+		t.Fatalf("-bundle pulled = %v, want only the base apk", device.pulled)
+	}
+}
+
+func TestPullSplitsZipProducesApksArchive(t *testing.T) {
+	resetSplitFlags()
+	zipSplits = true
+	defer resetSplitFlags()
+
+	device := &fakeSplitDevice{}
+	pkg := &Package{Name: "com.example"}
+	splits := []Split{
+		{Name: "base.apk", Path: "/device/base.apk"},
+		{Name: "split_config.arm64_v8a.apk", Path: "/device/split_config.arm64_v8a.apk"},
+	}
+	outDir := t.TempDir()
+
+	path, err := pullSplits(device, pkg, splits, "com.example.apk", outDir)
+	if err != nil {
+		t.Fatalf("pullSplits() error = %v", err)
+	}
+
+	wantApks := filepath.Join(outDir, "com.example.apks")
+	if path != wantApks {
+		t.Fatalf("-zip path = %q, want %q", path, wantApks)
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer r.Close()
+
+	names := make(map[string]bool, len(r.File))
+	var manifest apksManifest
+	for _, f := range r.File {
+		names[f.Name] = true
+		if f.Name != "manifest.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening manifest.json entry: %v", err)
+		}
+		err = json.NewDecoder(rc).Decode(&manifest)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("decoding manifest.json: %v", err)
+		}
+	}
+
+	for _, split := range splits {
+		if !names[split.Name] {
+			t.Fatalf("archive missing entry for %s, got %v", split.Name, names)
+		}
+	}
+	if !names["manifest.json"] {
+		t.Fatalf("archive missing manifest.json, got %v", names)
+	}
+
+	if manifest.Package != pkg.Name {
+		t.Fatalf("manifest.Package = %q, want %q", manifest.Package, pkg.Name)
+	}
+	wantSplits := []string{"base.apk", "split_config.arm64_v8a.apk"}
+	if !reflect.DeepEqual(manifest.Splits, wantSplits) {
+		t.Fatalf("manifest.Splits = %v, want %v", manifest.Splits, wantSplits)
+	}
+}
@@ -0,0 +1,78 @@
+//
+// Android Package Puller
+//
+// Version lookup and version-pinned pulls using the fdroidcl
+// "appid:versionCode" convention.
+//
+
+package main // github.com/slightfoot/android-package-puller
+
+import (
+	"flag"
+	"strings"
+)
+
+// versionInfo is the versionCode/versionName pair reported by
+// `dumpsys package` for an installed app.
+type versionInfo struct {
+	Code string
+	Name string
+}
+
+var infoOnly bool
+
+func init() {
+	flag.BoolVar(&infoOnly, "info", false,
+		"Print version info for the selected package(s) and exit without pulling.")
+}
+
+// getVersionInfo fetches versionCode/versionName for pkgName by parsing
+// the `dumpsys package` output.
+func getVersionInfo(device DeviceAPI, pkgName string) (*versionInfo, error) {
+	cmd := device.Command("dumpsys", "package", pkgName)
+	data, err := cmd.Call()
+	if err != nil {
+		return nil, printError("Failed to retrieve version info: %s", err.Error())
+	}
+
+	info := parseVersionInfo(data)
+	if len(info.Code) == 0 {
+		return nil, printError("Could not find version info for %s", pkgName)
+	}
+
+	return info, nil
+}
+
+func parseVersionInfo(data string) *versionInfo {
+	info := &versionInfo{}
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+
+		if len(info.Code) == 0 {
+			if idx := strings.Index(line, "versionCode="); idx >= 0 {
+				fields := strings.Fields(line[idx+len("versionCode="):])
+				if len(fields) > 0 {
+					info.Code = fields[0]
+				}
+			}
+		}
+
+		if len(info.Name) == 0 && strings.HasPrefix(line, "versionName=") {
+			info.Name = strings.TrimPrefix(line, "versionName=")
+		}
+	}
+
+	return info
+}
+
+// splitAppID splits a "com.example.app:12345" argument into the package
+// name and the requested version code. versionCode is empty when none
+// was supplied.
+func splitAppID(arg string) (name string, versionCode string) {
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
@@ -0,0 +1,77 @@
+package main // github.com/slightfoot/android-package-puller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersionInfo(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want *versionInfo
+	}{
+		{
+			name: "normal dumpsys output",
+			data: "    versionCode=42 minSdk=21 targetSdk=33\n    versionName=1.2.3\n",
+			want: &versionInfo{Code: "42", Name: "1.2.3"},
+		},
+		{
+			name: "missing versionCode",
+			data: "    versionName=1.2.3\n",
+			want: &versionInfo{Code: "", Name: "1.2.3"},
+		},
+		{
+			name: "missing versionName",
+			data: "    versionCode=7 minSdk=21\n",
+			want: &versionInfo{Code: "7", Name: ""},
+		},
+		{
+			name: "first Package block wins when there are several",
+			data: "Package [com.example.old]\n" +
+				"    versionCode=1 minSdk=21\n" +
+				"    versionName=0.1\n" +
+				"Package [com.example]\n" +
+				"    versionCode=2 minSdk=21\n" +
+				"    versionName=0.2\n",
+			want: &versionInfo{Code: "1", Name: "0.1"},
+		},
+		{
+			name: "empty input",
+			data: "",
+			want: &versionInfo{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := parseVersionInfo(c.data)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("parseVersionInfo(%q) = %+v, want %+v", c.data, got, c.want)
+			}
+		})
+	}
+}
+
+func TestSplitAppID(t *testing.T) {
+	cases := []struct {
+		name            string
+		arg             string
+		wantName        string
+		wantVersionCode string
+	}{
+		{name: "no colon", arg: "com.example.app", wantName: "com.example.app", wantVersionCode: ""},
+		{name: "with colon", arg: "com.example.app:42", wantName: "com.example.app", wantVersionCode: "42"},
+		{name: "empty version after colon", arg: "com.example.app:", wantName: "com.example.app", wantVersionCode: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			name, versionCode := splitAppID(c.arg)
+			if name != c.wantName || versionCode != c.wantVersionCode {
+				t.Fatalf("splitAppID(%q) = (%q, %q), want (%q, %q)",
+					c.arg, name, versionCode, c.wantName, c.wantVersionCode)
+			}
+		})
+	}
+}